@@ -4,17 +4,20 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
-	"regexp"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
 // Embed all MCP files into the binary
-//go:embed resources/*.md resources/*.json prompts/*.md prompts/*.json
+//go:embed resources/*.md resources/*.json prompts/*.md prompts/*.json tools/*.json
 var mcpFS embed.FS
 
 // ResourceMeta represents metadata for a resource
@@ -40,6 +43,33 @@ type PromptMeta struct {
 	Arguments   []PromptArgument `json:"arguments"`
 }
 
+// SetupEmbeddedTools registers all tools from tools/reference.json against a
+// live Hugr endpoint. Unlike resources/prompts, tool handlers don't read
+// embedded files at call time; they make HTTP GraphQL requests using cfg.
+func SetupEmbeddedTools(srv *server.MCPServer, cfg HugrClientConfig) error {
+	refData, err := mcpFS.ReadFile("tools/reference.json")
+	if err != nil {
+		return fmt.Errorf("failed to read tools/reference.json: %w", err)
+	}
+
+	var toolMeta []ToolMeta
+	if err := json.Unmarshal(refData, &toolMeta); err != nil {
+		return fmt.Errorf("failed to parse tools/reference.json: %w", err)
+	}
+
+	for _, meta := range toolMeta {
+		name := meta.Name // capture for closure
+
+		tool := mcp.NewToolWithRawSchema(meta.Name, meta.Description, meta.InputSchema)
+
+		srv.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return handleHugrTool(ctx, cfg, name, request.Params.Arguments)
+		})
+	}
+
+	return nil
+}
+
 // SetupEmbeddedResources registers all resources from embedded files
 func SetupEmbeddedResources(srv *server.MCPServer) error {
 	// Read embedded resources metadata
@@ -121,13 +151,20 @@ func SetupEmbeddedPrompts(srv *server.MCPServer) error {
 
 		srv.AddPrompt(prompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
 			// Read template from embedded FS
-			template, err := mcpFS.ReadFile("prompts/" + filename)
+			raw, err := mcpFS.ReadFile("prompts/" + filename)
 			if err != nil {
 				return nil, fmt.Errorf("failed to read %s: %w", filename, err)
 			}
 
-			// Render template with arguments
-			rendered := renderTemplate(string(template), request.Params.Arguments)
+			tmpl, err := CachedTemplate(filename, string(raw))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+			}
+
+			rendered, err := tmpl.Render(request.Params.Arguments, embeddedPartialResolver)
+			if err != nil {
+				return nil, fmt.Errorf("failed to render %s: %w", filename, err)
+			}
 
 			return &mcp.GetPromptResult{
 				Description: description,
@@ -147,45 +184,75 @@ func SetupEmbeddedPrompts(srv *server.MCPServer) error {
 	return nil
 }
 
-// renderTemplate renders a Handlebars-style template with arguments
-// Supports: {{variable}} and {{#if variable}}...{{/if}}
-func renderTemplate(template string, args map[string]any) string {
-	result := template
-
-	// Process each argument
-	for key, value := range args {
-		valueStr := fmt.Sprint(value)
-
-		// Check if value is non-empty
-		hasValue := value != nil && valueStr != ""
-
-		if hasValue {
-			// Remove {{#if key}} and {{/if}} markers
-			result = strings.ReplaceAll(result, "{{#if "+key+"}}", "")
-			result = strings.ReplaceAll(result, "{{/if}}", "")
-
-			// Replace {{key}} with value
-			result = strings.ReplaceAll(result, "{{"+key+"}}", valueStr)
-		} else {
-			// Remove entire {{#if key}}...{{/if}} block
-			re := regexp.MustCompile(`(?s)\{\{#if ` + regexp.QuoteMeta(key) + `\}\}.*?\{\{/if\}\}`)
-			result = re.ReplaceAllString(result, "")
-		}
+// embeddedPartialResolver loads and compiles a sibling prompt template from
+// the embedded FS for {{> name}} tags.
+func embeddedPartialResolver(name string) (*Template, error) {
+	filename := name
+	if !strings.Contains(filename, ".") {
+		filename += ".md"
 	}
 
-	return result
+	raw, err := mcpFS.ReadFile("prompts/" + filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partial %s: %w", filename, err)
+	}
+	return CachedTemplate(filename, string(raw))
+}
+
+// embeddedResourceReader reads a resource's current content by URI, looking
+// it up against resources/reference.json.
+func embeddedResourceReader(uri string) ([]byte, error) {
+	refData, err := mcpFS.ReadFile("resources/reference.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resources/reference.json: %w", err)
+	}
+	var metas []ResourceMeta
+	if err := json.Unmarshal(refData, &metas); err != nil {
+		return nil, fmt.Errorf("failed to parse resources/reference.json: %w", err)
+	}
+	for _, m := range metas {
+		if m.URI == uri {
+			return mcpFS.ReadFile("resources/" + m.Filename)
+		}
+	}
+	return nil, fmt.Errorf("unknown resource uri %q", uri)
 }
 
 // NewEmbeddedHugrMCPServer creates a Hugr MCP server with embedded resources
-func NewEmbeddedHugrMCPServer() (*server.MCPServer, error) {
+func NewEmbeddedHugrMCPServer(opts ...Option) (*server.MCPServer, error) {
+	var options serverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var subMgr *subscriptionManager
+	serverOpts := []server.ServerOption{
+		server.WithResourceCapabilities(options.subscriptions != nil, true), // listChanged=true
+		server.WithPromptCapabilities(true),                                 // listChanged=true
+		server.WithResourceRecovery(),                                       // recover from panics
+	}
+	if options.subscriptions != nil {
+		subMgr = newSubscriptionManager(*options.subscriptions, embeddedResourceReader)
+		serverOpts = append(serverOpts,
+			server.WithResourceSubscriptionHandlers(subMgr.onSubscribe, subMgr.onUnsubscribe),
+			server.WithHooks(&server.Hooks{
+				OnUnregisterSession: []server.OnUnregisterSessionHookFunc{
+					func(ctx context.Context, session server.ClientSession) {
+						subMgr.removeSession(session.SessionID())
+					},
+				},
+			}),
+		)
+	}
+
 	// Create server with capabilities
-	srv := server.NewMCPServer(
-		"hugr-mcp-server",
-		"1.0.0",
-		server.WithResourceCapabilities(false, true), // subscribe=false, listChanged=true
-		server.WithPromptCapabilities(true),          // listChanged=true
-		server.WithResourceRecovery(),                // recover from panics
-	)
+	srv := server.NewMCPServer("hugr-mcp-server", "1.0.0", serverOpts...)
+
+	if subMgr != nil {
+		subMgr.srv = srv
+		setActiveSubscriptionManager(subMgr)
+		go subMgr.pollForever(context.Background())
+	}
 
 	// Setup embedded resources
 	if err := SetupEmbeddedResources(srv); err != nil {
@@ -197,38 +264,37 @@ func NewEmbeddedHugrMCPServer() (*server.MCPServer, error) {
 		return nil, fmt.Errorf("failed to setup prompts: %w", err)
 	}
 
+	// Setup tools, if a live Hugr endpoint was configured
+	if options.hugrClient != nil {
+		if err := SetupEmbeddedTools(srv, *options.hugrClient); err != nil {
+			return nil, fmt.Errorf("failed to setup tools: %w", err)
+		}
+	}
+
 	return srv, nil
 }
 
 // Example usage with embedded files
 func main() {
-	// Create server with embedded resources/prompts
+	// Create server with embedded resources/prompts.
+	// To additionally expose the hugr.* tools against a live endpoint:
+	//   srv, err := NewEmbeddedHugrMCPServer(WithHugrClient(HugrClientConfig{
+	//       Endpoint: os.Getenv("HUGR_ENDPOINT"),
+	//       Token:    os.Getenv("HUGR_TOKEN"),
+	//   }))
 	srv, err := NewEmbeddedHugrMCPServer()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create server: %v\n", err)
 		os.Exit(1)
 	}
 
-	ctx := context.Background()
-
-	// For stdio transport (Claude Desktop):
-	// transport := server.NewStdioServerTransport()
-	// if err := srv.Serve(ctx, transport); err != nil {
-	//     fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
-	//     os.Exit(1)
-	// }
-
-	// For SSE transport (web):
-	// transport := server.NewSSEServerTransport("localhost:3000")
-	// if err := srv.Serve(ctx, transport); err != nil {
-	//     fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
-	//     os.Exit(1)
-	// }
-
-	fmt.Println("Hugr MCP Server (embedded) configured successfully!")
-	fmt.Println("All resources and prompts are embedded in the binary.")
-	fmt.Printf("Resources: 4 (embedded)\n")
-	fmt.Printf("Prompts: 3 (embedded)\n")
-	fmt.Println("\nBuild with: go build -o hugr-mcp-server")
-	fmt.Println("Binary will be self-contained with no external dependencies.")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Transport is selected via HUGR_MCP_TRANSPORT (stdio|sse|http|ws); see
+	// TransportConfigFromEnv for the full set of env vars.
+	if err := Serve(ctx, srv, TransportConfigFromEnv()); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("server error", "err", err)
+		os.Exit(1)
+	}
 }
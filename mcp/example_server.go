@@ -4,11 +4,14 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"syscall"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -41,154 +44,247 @@ type PromptMeta struct {
 	Arguments   []PromptArgument `json:"arguments"`
 }
 
-// SetupResources registers all resources from resources/reference.json
-func SetupResources(srv *server.MCPServer, resourcesPath string) error {
-	// Read resources metadata
+// SetupTools registers all tools from tools/reference.json against a live
+// Hugr endpoint. Unlike resources/prompts, tool handlers don't read files at
+// call time; they make HTTP GraphQL requests using cfg.
+func SetupTools(srv *server.MCPServer, toolsPath string, cfg HugrClientConfig) error {
+	refData, err := os.ReadFile(filepath.Join(toolsPath, "reference.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read tools/reference.json: %w", err)
+	}
+
+	var toolMeta []ToolMeta
+	if err := json.Unmarshal(refData, &toolMeta); err != nil {
+		return fmt.Errorf("failed to parse tools/reference.json: %w", err)
+	}
+
+	for _, meta := range toolMeta {
+		name := meta.Name // capture for closure
+
+		tool := mcp.NewToolWithRawSchema(meta.Name, meta.Description, meta.InputSchema)
+
+		srv.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return handleHugrTool(ctx, cfg, name, request.Params.Arguments)
+		})
+	}
+
+	return nil
+}
+
+// loadResourceMeta reads and parses resources/reference.json.
+func loadResourceMeta(resourcesPath string) ([]ResourceMeta, error) {
 	refData, err := os.ReadFile(filepath.Join(resourcesPath, "reference.json"))
 	if err != nil {
-		return fmt.Errorf("failed to read resources/reference.json: %w", err)
+		return nil, fmt.Errorf("failed to read resources/reference.json: %w", err)
 	}
 
 	var resourceMeta []ResourceMeta
 	if err := json.Unmarshal(refData, &resourceMeta); err != nil {
-		return fmt.Errorf("failed to parse resources/reference.json: %w", err)
+		return nil, fmt.Errorf("failed to parse resources/reference.json: %w", err)
 	}
+	return resourceMeta, nil
+}
 
-	// Register each resource
-	for _, meta := range resourceMeta {
-		filename := meta.Filename // capture for closure
+// registerResource registers a single resource against srv. Re-registering
+// an already-known URI replaces its handler, which Watch relies on when a
+// resource's metadata changes.
+func registerResource(srv *server.MCPServer, resourcesPath string, meta ResourceMeta) {
+	filename := meta.Filename // capture for closure
+
+	resource := mcp.NewResource(
+		meta.URI,
+		meta.Name,
+		mcp.WithResourceDescription(meta.Description),
+		mcp.WithMIMEType("text/markdown"),
+	)
 
-		resource := mcp.NewResource(
-			meta.URI,
-			meta.Name,
-			mcp.WithResourceDescription(meta.Description),
-			mcp.WithMIMEType("text/markdown"),
-		)
+	srv.AddResource(resource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		content, err := os.ReadFile(filepath.Join(resourcesPath, filename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
 
-		srv.AddResource(resource, func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
-			content, err := os.ReadFile(filepath.Join(resourcesPath, filename))
-			if err != nil {
-				return nil, fmt.Errorf("failed to read %s: %w", filename, err)
-			}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "text/markdown",
+				Text:     string(content),
+			},
+		}, nil
+	})
+}
 
-			return []mcp.ResourceContents{
-				mcp.TextResourceContents{
-					URI:      request.Params.URI,
-					MIMEType: "text/markdown",
-					Text:     string(content),
-				},
-			}, nil
-		})
+// SetupResources registers all resources from resources/reference.json
+func SetupResources(srv *server.MCPServer, resourcesPath string) error {
+	resourceMeta, err := loadResourceMeta(resourcesPath)
+	if err != nil {
+		return err
+	}
+
+	for _, meta := range resourceMeta {
+		registerResource(srv, resourcesPath, meta)
 	}
 
 	return nil
 }
 
-// SetupPrompts registers all prompts from prompts/reference.json
-func SetupPrompts(srv *server.MCPServer, promptsPath string) error {
-	// Read prompts metadata
+// loadPromptMeta reads and parses prompts/reference.json.
+func loadPromptMeta(promptsPath string) ([]PromptMeta, error) {
 	refData, err := os.ReadFile(filepath.Join(promptsPath, "reference.json"))
 	if err != nil {
-		return fmt.Errorf("failed to read prompts/reference.json: %w", err)
+		return nil, fmt.Errorf("failed to read prompts/reference.json: %w", err)
 	}
 
 	var promptMeta []PromptMeta
 	if err := json.Unmarshal(refData, &promptMeta); err != nil {
-		return fmt.Errorf("failed to parse prompts/reference.json: %w", err)
+		return nil, fmt.Errorf("failed to parse prompts/reference.json: %w", err)
 	}
+	return promptMeta, nil
+}
 
-	// Register each prompt
-	for _, meta := range promptMeta {
-		filename := meta.Filename       // capture for closure
-		description := meta.Description // capture for closure
+// registerPrompt registers a single prompt against srv. Re-registering an
+// already-known name replaces its definition and handler, which Watch relies
+// on when a prompt's metadata changes.
+func registerPrompt(srv *server.MCPServer, promptsPath string, meta PromptMeta) {
+	filename := meta.Filename       // capture for closure
+	description := meta.Description // capture for closure
+
+	// Build prompt definition
+	promptOpts := []mcp.PromptOption{
+		mcp.WithPromptDescription(meta.Description),
+	}
 
-		// Build prompt definition
-		promptOpts := []mcp.PromptOption{
-			mcp.WithPromptDescription(meta.Description),
+	for _, arg := range meta.Arguments {
+		argOpts := []mcp.PromptArgumentOption{
+			mcp.ArgumentDescription(arg.Description),
 		}
+		if arg.Required {
+			argOpts = append(argOpts, mcp.RequiredArgument())
+		}
+		promptOpts = append(promptOpts, mcp.WithArgument(arg.Name, argOpts...))
+	}
 
-		for _, arg := range meta.Arguments {
-			argOpts := []mcp.PromptArgumentOption{
-				mcp.ArgumentDescription(arg.Description),
-			}
-			if arg.Required {
-				argOpts = append(argOpts, mcp.RequiredArgument())
-			}
-			promptOpts = append(promptOpts, mcp.WithArgument(arg.Name, argOpts...))
+	prompt := mcp.NewPrompt(meta.Name, promptOpts...)
+
+	srv.AddPrompt(prompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		// Read template
+		raw, err := os.ReadFile(filepath.Join(promptsPath, filename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
 		}
 
-		prompt := mcp.NewPrompt(meta.Name, promptOpts...)
+		tmpl, err := CachedTemplate(filename, string(raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
 
-		srv.AddPrompt(prompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
-			// Read template
-			template, err := os.ReadFile(filepath.Join(promptsPath, filename))
-			if err != nil {
-				return nil, fmt.Errorf("failed to read %s: %w", filename, err)
-			}
+		rendered, err := tmpl.Render(request.Params.Arguments, diskPartialResolver(promptsPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", filename, err)
+		}
 
-			// Render template with arguments
-			rendered := renderTemplate(string(template), request.Params.Arguments)
-
-			return &mcp.GetPromptResult{
-				Description: description,
-				Messages: []mcp.PromptMessage{
-					{
-						Role: mcp.RoleUser,
-						Content: mcp.TextContent{
-							Type: "text",
-							Text: rendered,
-						},
+		return &mcp.GetPromptResult{
+			Description: description,
+			Messages: []mcp.PromptMessage{
+				{
+					Role: mcp.RoleUser,
+					Content: mcp.TextContent{
+						Type: "text",
+						Text: rendered,
 					},
 				},
-			}, nil
-		})
+			},
+		}, nil
+	})
+}
+
+// SetupPrompts registers all prompts from prompts/reference.json
+func SetupPrompts(srv *server.MCPServer, promptsPath string) error {
+	promptMeta, err := loadPromptMeta(promptsPath)
+	if err != nil {
+		return err
+	}
+
+	for _, meta := range promptMeta {
+		registerPrompt(srv, promptsPath, meta)
 	}
 
 	return nil
 }
 
-// renderTemplate renders a Handlebars-style template with arguments
-// Supports: {{variable}} and {{#if variable}}...{{/if}}
-func renderTemplate(template string, args map[string]any) string {
-	result := template
-
-	// Process each argument
-	for key, value := range args {
-		valueStr := fmt.Sprint(value)
-
-		// Check if value is non-empty
-		hasValue := value != nil && valueStr != ""
-
-		if hasValue {
-			// Remove {{#if key}} and {{/if}} markers
-			result = strings.ReplaceAll(result, "{{#if "+key+"}}", "")
-			result = strings.ReplaceAll(result, "{{/if}}", "")
-
-			// Replace {{key}} with value
-			result = strings.ReplaceAll(result, "{{"+key+"}}", valueStr)
-		} else {
-			// Remove entire {{#if key}}...{{/if}} block
-			re := regexp.MustCompile(`(?s)\{\{#if ` + regexp.QuoteMeta(key) + `\}\}.*?\{\{/if\}\}`)
-			result = re.ReplaceAllString(result, "")
+// diskPartialResolver returns a partial resolver that loads sibling prompt
+// templates from promptsPath for {{> name}} tags.
+func diskPartialResolver(promptsPath string) func(name string) (*Template, error) {
+	return func(name string) (*Template, error) {
+		filename := name
+		if !strings.Contains(filename, ".") {
+			filename += ".md"
 		}
+
+		raw, err := os.ReadFile(filepath.Join(promptsPath, filename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read partial %s: %w", filename, err)
+		}
+		return CachedTemplate(filename, string(raw))
 	}
+}
 
-	return result
+// diskResourceReader reads a resource's current content by URI, looking it
+// up against resources/reference.json.
+func diskResourceReader(resourcesPath string) func(uri string) ([]byte, error) {
+	return func(uri string) ([]byte, error) {
+		metas, err := loadResourceMeta(resourcesPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range metas {
+			if m.URI == uri {
+				return os.ReadFile(filepath.Join(resourcesPath, m.Filename))
+			}
+		}
+		return nil, fmt.Errorf("unknown resource uri %q", uri)
+	}
 }
 
 // NewHugrMCPServer creates a new Hugr MCP server with resources and prompts
-func NewHugrMCPServer(mcpPath string) (*server.MCPServer, error) {
+func NewHugrMCPServer(mcpPath string, opts ...Option) (*server.MCPServer, error) {
+	var options serverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	resourcesPath := filepath.Join(mcpPath, "resources")
+
+	var subMgr *subscriptionManager
+	serverOpts := []server.ServerOption{
+		server.WithResourceCapabilities(options.subscriptions != nil, true), // listChanged=true
+		server.WithPromptCapabilities(true),                                 // listChanged=true
+		server.WithResourceRecovery(),                                       // recover from panics
+	}
+	if options.subscriptions != nil {
+		subMgr = newSubscriptionManager(*options.subscriptions, diskResourceReader(resourcesPath))
+		serverOpts = append(serverOpts,
+			server.WithResourceSubscriptionHandlers(subMgr.onSubscribe, subMgr.onUnsubscribe),
+			server.WithHooks(&server.Hooks{
+				OnUnregisterSession: []server.OnUnregisterSessionHookFunc{
+					func(ctx context.Context, session server.ClientSession) {
+						subMgr.removeSession(session.SessionID())
+					},
+				},
+			}),
+		)
+	}
+
 	// Create server with capabilities
-	srv := server.NewMCPServer(
-		"hugr-mcp-server",
-		"1.0.0",
-		server.WithResourceCapabilities(false, true), // subscribe=false, listChanged=true
-		server.WithPromptCapabilities(true),          // listChanged=true
-		server.WithResourceRecovery(),                // recover from panics
-	)
+	srv := server.NewMCPServer("hugr-mcp-server", "1.0.0", serverOpts...)
+
+	if subMgr != nil {
+		subMgr.srv = srv
+		setActiveSubscriptionManager(subMgr)
+		go subMgr.pollForever(context.Background())
+	}
 
 	// Setup resources
-	resourcesPath := filepath.Join(mcpPath, "resources")
 	if err := SetupResources(srv, resourcesPath); err != nil {
 		return nil, fmt.Errorf("failed to setup resources: %w", err)
 	}
@@ -199,6 +295,14 @@ func NewHugrMCPServer(mcpPath string) (*server.MCPServer, error) {
 		return nil, fmt.Errorf("failed to setup prompts: %w", err)
 	}
 
+	// Setup tools, if a live Hugr endpoint was configured
+	if options.hugrClient != nil {
+		toolsPath := filepath.Join(mcpPath, "tools")
+		if err := SetupTools(srv, toolsPath, *options.hugrClient); err != nil {
+			return nil, fmt.Errorf("failed to setup tools: %w", err)
+		}
+	}
+
 	return srv, nil
 }
 
@@ -207,26 +311,35 @@ func main() {
 	// Path to mcp directory
 	mcpPath := "./mcp"
 
-	// Create server
+	// Create server.
+	// To additionally expose the hugr.* tools against a live endpoint:
+	//   srv, err := NewHugrMCPServer(mcpPath, WithHugrClient(HugrClientConfig{
+	//       Endpoint: os.Getenv("HUGR_ENDPOINT"),
+	//       Token:    os.Getenv("HUGR_TOKEN"),
+	//   }))
 	srv, err := NewHugrMCPServer(mcpPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create server: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Start server (example with stdio transport)
-	// You can also use SSE or WebSocket transports
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// For stdio transport:
-	// transport := server.NewStdioServerTransport()
-	// if err := srv.Serve(ctx, transport); err != nil {
-	//     fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
-	//     os.Exit(1)
-	// }
+	// Live-authoring mode: re-register resources/prompts as their files
+	// change, instead of requiring a restart.
+	if os.Getenv("HUGR_MCP_WATCH") != "" {
+		go func() {
+			if err := Watch(ctx, srv, mcpPath); err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("watch error", "err", err)
+			}
+		}()
+	}
 
-	fmt.Println("Hugr MCP Server configured successfully!")
-	fmt.Printf("Resources: 4\n")
-	fmt.Printf("Prompts: 3\n")
-	fmt.Println("\nServer ready to start with transport (stdio/sse/websocket)")
+	// Transport is selected via HUGR_MCP_TRANSPORT (stdio|sse|http|ws); see
+	// TransportConfigFromEnv for the full set of env vars.
+	if err := Serve(ctx, srv, TransportConfigFromEnv()); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("server error", "err", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxToolResultChunkBytes bounds how much text we pack into a single MCP
+// content block; larger results are split across multiple blocks so clients
+// that stream content don't have to buffer the whole response.
+const maxToolResultChunkBytes = 32 * 1024
+
+// ToolMeta represents metadata for a tool, loaded from tools/reference.json.
+type ToolMeta struct {
+	Name         string          `json:"name"`
+	Description  string          `json:"description"`
+	InputSchema  json.RawMessage `json:"input_schema"`
+	OutputSchema json.RawMessage `json:"output_schema"`
+}
+
+// HugrClientConfig configures access to a live Hugr GraphQL endpoint. Tools
+// are registered whenever a config is passed via WithHugrClient, even a
+// zero-valued one; without that option the server stays in read-only docs
+// mode.
+type HugrClientConfig struct {
+	// Endpoint is the Hugr GraphQL HTTP endpoint, e.g. https://hugr.example.com/graphql.
+	Endpoint string
+	// Token is sent as a bearer token on every request, if set.
+	Token string
+	// HTTPClient is used to execute requests; http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+func (c HugrClientConfig) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// execute runs a GraphQL query/mutation against the configured endpoint and
+// returns the raw `data` field of the response.
+func (c HugrClientConfig) execute(ctx context.Context, query string, variables map[string]any) (json.RawMessage, error) {
+	if c.Endpoint == "" {
+		return nil, fmt.Errorf("hugr client: endpoint is not configured")
+	}
+
+	body, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode graphql request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graphql request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("hugr request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("hugr endpoint returned status %s", resp.Status)
+	}
+
+	var gqlResp graphQLResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gqlResp); err != nil {
+		return nil, fmt.Errorf("failed to decode graphql response: %w", err)
+	}
+	if len(gqlResp.Errors) > 0 {
+		return nil, fmt.Errorf("hugr graphql error: %s", gqlResp.Errors[0].Message)
+	}
+
+	return gqlResp.Data, nil
+}
+
+// chunkToolResult splits data into MCP text content blocks of at most
+// maxToolResultChunkBytes so large query results stream back instead of
+// arriving as one oversized block.
+func chunkToolResult(data []byte) []mcp.Content {
+	if len(data) == 0 {
+		return []mcp.Content{mcp.TextContent{Type: "text", Text: "null"}}
+	}
+
+	var blocks []mcp.Content
+	for len(data) > 0 {
+		n := maxToolResultChunkBytes
+		if n >= len(data) {
+			n = len(data)
+		} else {
+			// Back off to the start of a rune so we don't split a
+			// multi-byte UTF-8 character across two chunks.
+			for n > 0 && !utf8.RuneStart(data[n]) {
+				n--
+			}
+		}
+		blocks = append(blocks, mcp.TextContent{Type: "text", Text: string(data[:n])})
+		data = data[n:]
+	}
+	return blocks
+}
+
+// handleHugrTool dispatches a CallToolRequest to the Hugr GraphQL endpoint
+// based on the tool name, shaping the request the way each tool promises in
+// tools/reference.json.
+func handleHugrTool(ctx context.Context, client HugrClientConfig, name string, args map[string]any) (*mcp.CallToolResult, error) {
+	switch name {
+	case "hugr.query":
+		query, _ := args["query"].(string)
+		if query == "" {
+			return mcp.NewToolResultError("query is required"), nil
+		}
+		variables, _ := args["variables"].(map[string]any)
+		data, err := client.execute(ctx, query, variables)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return &mcp.CallToolResult{Content: chunkToolResult(data)}, nil
+
+	case "hugr.list_datasources":
+		data, err := client.execute(ctx, `query { datasources { name type } }`, nil)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return &mcp.CallToolResult{Content: chunkToolResult(data)}, nil
+
+	case "hugr.describe_dataset":
+		dataset, _ := args["dataset"].(string)
+		if dataset == "" {
+			return mcp.NewToolResultError("dataset is required"), nil
+		}
+		query := `query($name: String!) { dataset(name: $name) { name fields { name type } } }`
+		data, err := client.execute(ctx, query, map[string]any{"name": dataset})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return &mcp.CallToolResult{Content: chunkToolResult(data)}, nil
+
+	case "hugr.run_saved_query":
+		savedName, _ := args["name"].(string)
+		if savedName == "" {
+			return mcp.NewToolResultError("name is required"), nil
+		}
+		variables, _ := args["variables"].(map[string]any)
+		query := `query($name: String!, $variables: JSON) { savedQuery(name: $name, variables: $variables) }`
+		data, err := client.execute(ctx, query, map[string]any{"name": savedName, "variables": variables})
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return &mcp.CallToolResult{Content: chunkToolResult(data)}, nil
+
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("unknown tool %q", name)), nil
+	}
+}
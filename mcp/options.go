@@ -0,0 +1,28 @@
+package main
+
+// Option configures optional behavior of NewHugrMCPServer /
+// NewEmbeddedHugrMCPServer, such as enabling live tools.
+type Option func(*serverOptions)
+
+type serverOptions struct {
+	hugrClient    *HugrClientConfig
+	subscriptions *SubscriptionConfig
+}
+
+// WithHugrClient enables the hugr.* tools, backed by a live Hugr GraphQL
+// endpoint. Without this option the server only serves static resources and
+// prompts ("read-only docs" mode).
+func WithHugrClient(cfg HugrClientConfig) Option {
+	return func(o *serverOptions) {
+		o.hugrClient = &cfg
+	}
+}
+
+// WithSubscriptions enables per-resource subscriptions (clients can ask to
+// be notified when a specific resource's content changes) and tunes the
+// content-hash polling behavior.
+func WithSubscriptions(cfg SubscriptionConfig) Option {
+	return func(o *serverOptions) {
+		o.subscriptions = &cfg
+	}
+}
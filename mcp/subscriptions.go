@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const defaultSubscriptionPollInterval = 30 * time.Second
+
+// SubscriptionConfig tunes per-resource subscription behavior.
+type SubscriptionConfig struct {
+	// PollInterval is how often to re-hash subscribed resources and check
+	// for changes. Used unconditionally by the embedded build (which has no
+	// mtimes to watch) and as a fallback by the disk build between
+	// fsnotify-triggered checks. Defaults to 30s.
+	PollInterval time.Duration
+	// MaxSubscribersPerURI caps how many sessions may subscribe to a single
+	// resource URI at once. Zero means unlimited.
+	MaxSubscribersPerURI int
+}
+
+func (c SubscriptionConfig) pollInterval() time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return defaultSubscriptionPollInterval
+}
+
+// subscriptionManager tracks which sessions are subscribed to which resource
+// URIs and notifies them when a resource's content hash changes. read loads
+// the current content for a URI (from the embedded FS or disk, depending on
+// build).
+type subscriptionManager struct {
+	srv  *server.MCPServer
+	cfg  SubscriptionConfig
+	read func(uri string) ([]byte, error)
+
+	mu          sync.Mutex
+	subscribers map[string]map[string]struct{} // uri -> sessionID -> {}
+	hashes      map[string]string              // uri -> last seen sha256
+}
+
+func newSubscriptionManager(cfg SubscriptionConfig, read func(uri string) ([]byte, error)) *subscriptionManager {
+	return &subscriptionManager{
+		cfg:         cfg,
+		read:        read,
+		subscribers: map[string]map[string]struct{}{},
+		hashes:      map[string]string{},
+	}
+}
+
+func (m *subscriptionManager) onSubscribe(ctx context.Context, sessionID, uri string) error {
+	// Seed the baseline hash outside the lock (read may do I/O) so the first
+	// real change after this subscribe is detected instead of being mistaken
+	// for the baseline by checkResource.
+	var seedHash string
+	if data, err := m.read(uri); err == nil {
+		sum := sha256.Sum256(data)
+		seedHash = hex.EncodeToString(sum[:])
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subs := m.subscribers[uri]
+	if subs == nil {
+		subs = map[string]struct{}{}
+		m.subscribers[uri] = subs
+	}
+	_, already := subs[sessionID]
+	if !already && m.cfg.MaxSubscribersPerURI > 0 && len(subs) >= m.cfg.MaxSubscribersPerURI {
+		return fmt.Errorf("resource %s already has the maximum of %d subscribers", uri, m.cfg.MaxSubscribersPerURI)
+	}
+	subs[sessionID] = struct{}{}
+	if seedHash != "" && m.hashes[uri] == "" {
+		m.hashes[uri] = seedHash
+	}
+	return nil
+}
+
+func (m *subscriptionManager) onUnsubscribe(ctx context.Context, sessionID, uri string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeLocked(uri, sessionID)
+	return nil
+}
+
+// removeSession drops every subscription held by sessionID; called on
+// session disconnect to avoid leaking subscriber entries.
+func (m *subscriptionManager) removeSession(sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for uri := range m.subscribers {
+		m.removeLocked(uri, sessionID)
+	}
+}
+
+func (m *subscriptionManager) removeLocked(uri, sessionID string) {
+	delete(m.subscribers[uri], sessionID)
+	if len(m.subscribers[uri]) == 0 {
+		delete(m.subscribers, uri)
+	}
+}
+
+// checkResource re-reads and re-hashes a single resource, notifying
+// subscribers if its content changed since the last check.
+func (m *subscriptionManager) checkResource(uri string) {
+	data, err := m.read(uri)
+	if err != nil {
+		slog.Error("failed to read resource for subscription check", "uri", uri, "err", err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	m.mu.Lock()
+	changed := m.hashes[uri] != "" && m.hashes[uri] != hash
+	m.hashes[uri] = hash
+	var sessionIDs []string
+	if changed {
+		for sessionID := range m.subscribers[uri] {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, sessionID := range sessionIDs {
+		if err := m.srv.SendNotificationToSpecificClient(sessionID, "notifications/resources/updated", map[string]any{"uri": uri}); err != nil {
+			slog.Error("failed to notify subscriber", "uri", uri, "session", sessionID, "err", err)
+		}
+	}
+}
+
+// checkAll re-hashes every URI that currently has at least one subscriber.
+func (m *subscriptionManager) checkAll() {
+	m.mu.Lock()
+	uris := make([]string, 0, len(m.subscribers))
+	for uri := range m.subscribers {
+		uris = append(uris, uri)
+	}
+	m.mu.Unlock()
+
+	for _, uri := range uris {
+		m.checkResource(uri)
+	}
+}
+
+// pollForever periodically re-hashes subscribed resources until ctx is
+// canceled. The embedded build relies on this exclusively; the disk build
+// additionally gets faster, event-driven checks from Watch.
+func (m *subscriptionManager) pollForever(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkAll()
+		}
+	}
+}
+
+// activeSubscriptions lets Watch (which runs independently of server
+// construction) push fsnotify-triggered checks into whichever subscription
+// manager the running server created, without the two having to be wired
+// together explicitly.
+var (
+	activeSubscriptionsMu sync.Mutex
+	activeSubscriptions   *subscriptionManager
+)
+
+func setActiveSubscriptionManager(m *subscriptionManager) {
+	activeSubscriptionsMu.Lock()
+	defer activeSubscriptionsMu.Unlock()
+	activeSubscriptions = m
+}
+
+func notifySubscriptionManagerOfChange(uri string) {
+	activeSubscriptionsMu.Lock()
+	m := activeSubscriptions
+	activeSubscriptionsMu.Unlock()
+	if m != nil {
+		m.checkResource(uri)
+	}
+}
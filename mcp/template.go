@@ -0,0 +1,408 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tagPattern matches both {{{triple}}} and {{double}} tags; the triple-brace
+// alternative is tried first so raw output isn't mistaken for a normal var.
+var tagPattern = regexp.MustCompile(`\{\{\{[\s\S]*?\}\}\}|\{\{[\s\S]*?\}\}`)
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenVar
+	tokenRawVar
+	tokenIf
+	tokenElse
+	tokenEndIf
+	tokenUnless
+	tokenEndUnless
+	tokenEach
+	tokenEndEach
+	tokenPartial
+)
+
+type token struct {
+	kind tokenKind
+	text string // literal text for tokenText, path/name for the rest
+}
+
+// tokenize splits src into a flat stream of text and tag tokens.
+func tokenize(src string) []token {
+	var toks []token
+	last := 0
+	for _, loc := range tagPattern.FindAllStringIndex(src, -1) {
+		if loc[0] > last {
+			toks = append(toks, token{kind: tokenText, text: src[last:loc[0]]})
+		}
+		toks = append(toks, parseTag(src[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	if last < len(src) {
+		toks = append(toks, token{kind: tokenText, text: src[last:]})
+	}
+	return toks
+}
+
+// parseTag classifies a single {{...}} / {{{...}}} match.
+func parseTag(raw string) token {
+	if strings.HasPrefix(raw, "{{{") && strings.HasSuffix(raw, "}}}") {
+		return token{kind: tokenRawVar, text: strings.TrimSpace(raw[3 : len(raw)-3])}
+	}
+
+	inner := strings.TrimSpace(raw[2 : len(raw)-2])
+	switch {
+	case strings.HasPrefix(inner, "#if "):
+		return token{kind: tokenIf, text: strings.TrimSpace(inner[4:])}
+	case inner == "else":
+		return token{kind: tokenElse}
+	case inner == "/if":
+		return token{kind: tokenEndIf}
+	case strings.HasPrefix(inner, "#unless "):
+		return token{kind: tokenUnless, text: strings.TrimSpace(inner[8:])}
+	case inner == "/unless":
+		return token{kind: tokenEndUnless}
+	case strings.HasPrefix(inner, "#each "):
+		return token{kind: tokenEach, text: strings.TrimSpace(inner[6:])}
+	case inner == "/each":
+		return token{kind: tokenEndEach}
+	case strings.HasPrefix(inner, "> "):
+		return token{kind: tokenPartial, text: strings.TrimSpace(inner[2:])}
+	default:
+		return token{kind: tokenVar, text: inner}
+	}
+}
+
+// node is one element of a parsed template's AST.
+type node interface{}
+
+type textNode string
+
+type varNode struct {
+	path string
+	raw  bool
+}
+
+type ifNode struct {
+	path      string
+	then, els []node
+}
+
+type unlessNode struct {
+	path string
+	body []node
+}
+
+type eachNode struct {
+	path string
+	body []node
+}
+
+type partialNode struct {
+	name string
+}
+
+// parser turns a token stream into a node tree via recursive descent, one
+// stack frame per nested block.
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peekKind() (tokenKind, bool) {
+	if p.pos >= len(p.toks) {
+		return 0, false
+	}
+	return p.toks[p.pos].kind, true
+}
+
+// parseUntil parses nodes until it sees an unconsumed token whose kind is in
+// stop (which it leaves for the caller), or runs out of tokens.
+func (p *parser) parseUntil(stop ...tokenKind) ([]node, error) {
+	var nodes []node
+	for {
+		kind, ok := p.peekKind()
+		if !ok {
+			return nodes, nil
+		}
+		for _, s := range stop {
+			if kind == s {
+				return nodes, nil
+			}
+		}
+
+		tok := p.toks[p.pos]
+		p.pos++
+
+		switch tok.kind {
+		case tokenText:
+			nodes = append(nodes, textNode(tok.text))
+		case tokenVar:
+			nodes = append(nodes, varNode{path: tok.text})
+		case tokenRawVar:
+			nodes = append(nodes, varNode{path: tok.text, raw: true})
+		case tokenPartial:
+			nodes = append(nodes, partialNode{name: tok.text})
+		case tokenIf:
+			thenNodes, err := p.parseUntil(tokenElse, tokenEndIf)
+			if err != nil {
+				return nil, err
+			}
+			var elseNodes []node
+			if k, ok := p.peekKind(); ok && k == tokenElse {
+				p.pos++
+				elseNodes, err = p.parseUntil(tokenEndIf)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if k, ok := p.peekKind(); !ok || k != tokenEndIf {
+				return nil, fmt.Errorf("template: missing {{/if}} for {{#if %s}}", tok.text)
+			}
+			p.pos++
+			nodes = append(nodes, ifNode{path: tok.text, then: thenNodes, els: elseNodes})
+		case tokenUnless:
+			body, err := p.parseUntil(tokenEndUnless)
+			if err != nil {
+				return nil, err
+			}
+			if k, ok := p.peekKind(); !ok || k != tokenEndUnless {
+				return nil, fmt.Errorf("template: missing {{/unless}} for {{#unless %s}}", tok.text)
+			}
+			p.pos++
+			nodes = append(nodes, unlessNode{path: tok.text, body: body})
+		case tokenEach:
+			body, err := p.parseUntil(tokenEndEach)
+			if err != nil {
+				return nil, err
+			}
+			if k, ok := p.peekKind(); !ok || k != tokenEndEach {
+				return nil, fmt.Errorf("template: missing {{/each}} for {{#each %s}}", tok.text)
+			}
+			p.pos++
+			nodes = append(nodes, eachNode{path: tok.text, body: body})
+		default:
+			return nil, fmt.Errorf("template: unexpected tag of kind %d", tok.kind)
+		}
+	}
+}
+
+// Template is a parsed, ready-to-render prompt template.
+type Template struct {
+	root []node
+}
+
+// ParseTemplate compiles src into an AST.
+func ParseTemplate(src string) (*Template, error) {
+	p := &parser{toks: tokenize(src)}
+	nodes, err := p.parseUntil()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("template: unmatched closing tag")
+	}
+	return &Template{root: nodes}, nil
+}
+
+var (
+	templateCacheMu sync.Mutex
+	templateCache   = map[string]*Template{}
+)
+
+// CachedTemplate parses src and caches the result under key (typically the
+// prompt's filename) so repeated GetPrompt calls don't re-parse.
+func CachedTemplate(key, src string) (*Template, error) {
+	templateCacheMu.Lock()
+	defer templateCacheMu.Unlock()
+
+	if t, ok := templateCache[key]; ok {
+		return t, nil
+	}
+	t, err := ParseTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	templateCache[key] = t
+	return t, nil
+}
+
+// InvalidateTemplate drops a cached template so the next CachedTemplate call
+// re-parses it; used when the underlying prompt file changes on disk.
+func InvalidateTemplate(key string) {
+	templateCacheMu.Lock()
+	defer templateCacheMu.Unlock()
+	delete(templateCache, key)
+}
+
+// Render evaluates the template against data. resolvePartial loads and
+// compiles a sibling template by name for {{> name}}; it may be nil if the
+// template has no partials.
+func (t *Template) Render(data map[string]any, resolvePartial func(name string) (*Template, error)) (string, error) {
+	var sb strings.Builder
+	if err := renderNodes(&sb, t.root, data, resolvePartial); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func renderNodes(sb *strings.Builder, nodes []node, data map[string]any, resolvePartial func(string) (*Template, error)) error {
+	for _, n := range nodes {
+		switch v := n.(type) {
+		case textNode:
+			sb.WriteString(string(v))
+
+		case varNode:
+			val, ok := lookupPath(data, v.path)
+			if !ok {
+				continue
+			}
+			str := fmt.Sprint(val)
+			if v.raw {
+				sb.WriteString(str)
+			} else {
+				sb.WriteString(html.EscapeString(str))
+			}
+
+		case ifNode:
+			val, ok := lookupPath(data, v.path)
+			branch := v.then
+			if !truthy(val, ok) {
+				branch = v.els
+			}
+			if err := renderNodes(sb, branch, data, resolvePartial); err != nil {
+				return err
+			}
+
+		case unlessNode:
+			val, ok := lookupPath(data, v.path)
+			if !truthy(val, ok) {
+				if err := renderNodes(sb, v.body, data, resolvePartial); err != nil {
+					return err
+				}
+			}
+
+		case eachNode:
+			val, ok := lookupPath(data, v.path)
+			if !ok {
+				continue
+			}
+			for _, entry := range iterate(val) {
+				child := make(map[string]any, len(data)+3)
+				for k, dv := range data {
+					child[k] = dv
+				}
+				if m, ok := entry.value.(map[string]any); ok {
+					for k, fv := range m {
+						child[k] = fv
+					}
+				}
+				child["this"] = entry.value
+				child["@index"] = entry.index
+				child["@key"] = entry.key
+				if err := renderNodes(sb, v.body, child, resolvePartial); err != nil {
+					return err
+				}
+			}
+
+		case partialNode:
+			if resolvePartial == nil {
+				return fmt.Errorf("template: {{> %s}} used but no partial resolver is configured", v.name)
+			}
+			partial, err := resolvePartial(v.name)
+			if err != nil {
+				return fmt.Errorf("template: failed to resolve partial %q: %w", v.name, err)
+			}
+			if err := renderNodes(sb, partial.root, data, resolvePartial); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("template: unknown node type %T", n)
+		}
+	}
+	return nil
+}
+
+// entry is one iteration step of an {{#each}} block.
+type entry struct {
+	key   string
+	index int
+	value any
+}
+
+// iterate yields entries for a slice (index order, deterministic) or a
+// map[string]any (sorted by key, deterministic) so repeated renders of the
+// same arguments always produce the same output.
+func iterate(val any) []entry {
+	switch v := val.(type) {
+	case []any:
+		entries := make([]entry, len(v))
+		for i, item := range v {
+			entries[i] = entry{key: fmt.Sprint(i), index: i, value: item}
+		}
+		return entries
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]entry, len(keys))
+		for i, k := range keys {
+			entries[i] = entry{key: k, index: i, value: v[k]}
+		}
+		return entries
+	default:
+		return nil
+	}
+}
+
+// lookupPath resolves a dotted path ("user.name") against nested
+// map[string]any values.
+func lookupPath(data map[string]any, path string) (any, bool) {
+	var cur any = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		val, ok := m[part]
+		if !ok {
+			return nil, false
+		}
+		cur = val
+	}
+	return cur, true
+}
+
+// truthy mirrors Handlebars' notion of falsy: missing, nil, false, "", 0, and
+// empty slices/maps are falsy; everything else is truthy.
+func truthy(val any, ok bool) bool {
+	if !ok || val == nil {
+		return false
+	}
+	switch v := val.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	default:
+		return true
+	}
+}
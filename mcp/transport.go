@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// TransportKind selects which wire protocol Serve exposes the server over.
+type TransportKind string
+
+const (
+	TransportStdio TransportKind = "stdio"
+	TransportSSE   TransportKind = "sse"
+	TransportHTTP  TransportKind = "http" // MCP Streamable HTTP
+	TransportWS    TransportKind = "ws"
+)
+
+// TransportConfig configures Serve. Addr/TLS/CORS/BearerToken are only used
+// by the network transports (sse, http, ws); stdio ignores them.
+type TransportConfig struct {
+	Transport TransportKind
+
+	Addr        string
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// CORSOrigins is the allowlist for the Access-Control-Allow-Origin
+	// header; "*" allows any origin. Empty disables CORS handling entirely.
+	CORSOrigins []string
+
+	// BearerToken, if set, is required via "Authorization: Bearer <token>"
+	// on every request to a network transport.
+	BearerToken string
+}
+
+// TransportConfigFromEnv builds a TransportConfig from HUGR_MCP_TRANSPORT,
+// HUGR_MCP_ADDR, HUGR_MCP_TLS_CERT, HUGR_MCP_TLS_KEY, HUGR_MCP_CORS_ORIGINS
+// (comma-separated) and HUGR_MCP_BEARER_TOKEN.
+func TransportConfigFromEnv() TransportConfig {
+	cfg := TransportConfig{
+		Transport:   TransportKind(envOrDefault("HUGR_MCP_TRANSPORT", string(TransportStdio))),
+		Addr:        envOrDefault("HUGR_MCP_ADDR", ":8080"),
+		TLSCertFile: os.Getenv("HUGR_MCP_TLS_CERT"),
+		TLSKeyFile:  os.Getenv("HUGR_MCP_TLS_KEY"),
+		BearerToken: os.Getenv("HUGR_MCP_BEARER_TOKEN"),
+	}
+	if origins := os.Getenv("HUGR_MCP_CORS_ORIGINS"); origins != "" {
+		cfg.CORSOrigins = strings.Split(origins, ",")
+	}
+	return cfg
+}
+
+func envOrDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Serve runs srv over the transport selected by cfg until ctx is canceled
+// (e.g. on SIGINT/SIGTERM), logging connect/disconnect/request events via
+// slog, and returns once shutdown completes.
+func Serve(ctx context.Context, srv *server.MCPServer, cfg TransportConfig) error {
+	switch cfg.Transport {
+	case "", TransportStdio:
+		return serveStdio(ctx, srv)
+	case TransportSSE:
+		return serveHTTPTransport(ctx, "sse", server.NewSSEServer(srv), cfg)
+	case TransportHTTP:
+		return serveHTTPTransport(ctx, "http", server.NewStreamableHTTPServer(srv), cfg)
+	case TransportWS:
+		return serveHTTPTransport(ctx, "ws", newWebSocketHandler(srv, cfg), cfg)
+	default:
+		return fmt.Errorf("unsupported HUGR_MCP_TRANSPORT %q (want stdio, sse, http, or ws)", cfg.Transport)
+	}
+}
+
+func serveStdio(ctx context.Context, srv *server.MCPServer) error {
+	slog.Info("mcp server listening", "transport", "stdio")
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ServeStdio(srv) }()
+
+	select {
+	case <-ctx.Done():
+		slog.Info("mcp server shutting down", "transport", "stdio")
+		return ctx.Err()
+	case err := <-errCh:
+		return err
+	}
+}
+
+// serveHTTPTransport runs handler behind CORS/bearer-auth middleware on
+// cfg.Addr, with graceful shutdown when ctx is canceled.
+func serveHTTPTransport(ctx context.Context, name string, handler http.Handler, cfg TransportConfig) error {
+	handler = withBearerAuth(cfg.BearerToken, handler)
+	handler = withCORS(cfg.CORSOrigins, handler)
+
+	httpSrv := &http.Server{Addr: cfg.Addr, Handler: handler}
+	useTLS := cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("mcp server listening", "transport", name, "addr", cfg.Addr, "tls", useTLS)
+		var err error
+		if useTLS {
+			err = httpSrv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = httpSrv.ListenAndServe()
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		errCh <- err
+	}()
+
+	select {
+	case <-ctx.Done():
+		slog.Info("mcp server shutting down", "transport", name)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down %s transport: %w", name, err)
+		}
+		return <-errCh
+	case err := <-errCh:
+		return err
+	}
+}
+
+func withCORS(origins []string, next http.Handler) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[o] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if allowed["*"] || allowed[origin] {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func withBearerAuth(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wsSession adapts a single WebSocket connection to server.ClientSession so
+// that notifications triggered from outside the connection's own
+// request/response cycle (list_changed from Watch, resources/updated from
+// the subscription manager) can still reach ws clients, the same as they
+// reach sse/http clients, and so resource subscribe handlers see a real,
+// stable session ID instead of none at all.
+type wsSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+}
+
+func newWSSession() *wsSession {
+	return &wsSession{
+		id:            randomSessionID(),
+		notifications: make(chan mcp.JSONRPCNotification, 100),
+	}
+}
+
+func (s *wsSession) SessionID() string                                   { return s.id }
+func (s *wsSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+func (s *wsSession) Initialize()                                        { s.initialized.Store(true) }
+func (s *wsSession) Initialized() bool                                   { return s.initialized.Load() }
+
+func randomSessionID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// newWebSocketHandler bridges raw JSON-RPC frames over a WebSocket
+// connection to srv.HandleMessage, for long-lived agent frameworks that
+// don't speak SSE/Streamable HTTP. Each connection registers a ClientSession
+// so it can be resolved by resource subscribe handlers and torn down via the
+// same OnUnregisterSession hook the other transports use, and a pump
+// goroutine forwards async server notifications to the socket.
+func newWebSocketHandler(srv *server.MCPServer, cfg TransportConfig) http.Handler {
+	allowed := make(map[string]bool, len(cfg.CORSOrigins))
+	for _, o := range cfg.CORSOrigins {
+		allowed[o] = true
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			if len(allowed) == 0 {
+				return true
+			}
+			return allowed["*"] || allowed[r.Header.Get("Origin")]
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			slog.Error("websocket upgrade failed", "remote", r.RemoteAddr, "err", err)
+			return
+		}
+		defer conn.Close()
+
+		session := newWSSession()
+		if err := srv.RegisterSession(r.Context(), session); err != nil {
+			slog.Error("websocket session registration failed", "remote", r.RemoteAddr, "err", err)
+			return
+		}
+		defer srv.UnregisterSession(r.Context(), session.SessionID())
+
+		ctx := server.WithClientSession(r.Context(), session)
+
+		slog.Info("websocket client connected", "remote", r.RemoteAddr, "session", session.SessionID())
+		defer slog.Info("websocket client disconnected", "remote", r.RemoteAddr, "session", session.SessionID())
+
+		// conn is not safe for concurrent writes, but the read loop (writing
+		// responses) and the pump below (writing notifications) both write to
+		// it, so serialize them.
+		var writeMu sync.Mutex
+		done := make(chan struct{})
+		defer close(done)
+
+		go func() {
+			for {
+				select {
+				case notif, ok := <-session.notifications:
+					if !ok {
+						return
+					}
+					writeMu.Lock()
+					err := conn.WriteJSON(notif)
+					writeMu.Unlock()
+					if err != nil {
+						slog.Error("websocket notification write failed", "remote", r.RemoteAddr, "err", err)
+						return
+					}
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			resp := srv.HandleMessage(ctx, data)
+			if resp == nil {
+				continue
+			}
+			writeMu.Lock()
+			err = conn.WriteJSON(resp)
+			writeMu.Unlock()
+			if err != nil {
+				slog.Error("websocket write failed", "remote", r.RemoteAddr, "err", err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// watchDebounce absorbs the burst of events a single editor save produces
+// (write, then a rename of a temp file over the target, etc.).
+const watchDebounce = 200 * time.Millisecond
+
+// registry tracks the resources/prompts currently registered on srv so
+// Watch can diff a fresh reference.json against it. MCP handlers run
+// concurrently with the watcher goroutine, so mu guards every field.
+type registry struct {
+	mu        sync.Mutex
+	resources map[string]ResourceMeta // keyed by filename
+	prompts   map[string]PromptMeta   // keyed by filename
+}
+
+// Watch observes resources/ and prompts/ under mcpPath (including their
+// reference.json) and keeps srv's registered resources/prompts in sync with
+// the files on disk: added entries are registered, removed entries are
+// unregistered, and changed entries are re-registered. Modified prompt
+// templates have their cached AST invalidated. A listChanged notification is
+// sent after each reload that changed the registered set. Watch blocks until
+// ctx is canceled.
+func Watch(ctx context.Context, srv *server.MCPServer, mcpPath string) error {
+	resourcesPath := filepath.Join(mcpPath, "resources")
+	promptsPath := filepath.Join(mcpPath, "prompts")
+
+	reg := &registry{resources: map[string]ResourceMeta{}, prompts: map[string]PromptMeta{}}
+	if err := reg.reloadResources(srv, resourcesPath); err != nil {
+		return fmt.Errorf("failed initial resource scan: %w", err)
+	}
+	if err := reg.reloadPrompts(srv, promptsPath); err != nil {
+		return fmt.Errorf("failed initial prompt scan: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	for _, dir := range []string{resourcesPath, promptsPath} {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	slog.Info("watching for resource/prompt changes", "resources", resourcesPath, "prompts", promptsPath)
+
+	var timer *time.Timer
+	pending := make(chan struct{}, 1)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			// Handle content-only changes immediately; the debounced reload
+			// below only needs to catch up on additions, removals, and
+			// reference.json metadata changes.
+			switch dir, base := filepath.Dir(event.Name), filepath.Base(event.Name); {
+			case dir == promptsPath && base != "reference.json":
+				InvalidateTemplate(base)
+			case dir == resourcesPath && base != "reference.json":
+				if uri, ok := reg.uriForFilename(base); ok {
+					notifySubscriptionManagerOfChange(uri)
+				}
+			}
+
+			if timer == nil {
+				timer = time.AfterFunc(watchDebounce, func() {
+					select {
+					case pending <- struct{}{}:
+					default:
+					}
+				})
+			} else {
+				timer.Reset(watchDebounce)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("watcher error", "err", err)
+
+		case <-pending:
+			if err := reg.reloadResources(srv, resourcesPath); err != nil {
+				slog.Error("failed to reload resources", "err", err)
+			}
+			if err := reg.reloadPrompts(srv, promptsPath); err != nil {
+				slog.Error("failed to reload prompts", "err", err)
+			}
+		}
+	}
+}
+
+func (r *registry) uriForFilename(filename string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.resources[filename]
+	if !ok {
+		return "", false
+	}
+	return m.URI, true
+}
+
+func (r *registry) reloadResources(srv *server.MCPServer, resourcesPath string) error {
+	meta, err := loadResourceMeta(resourcesPath)
+	if err != nil {
+		return err
+	}
+	next := make(map[string]ResourceMeta, len(meta))
+	for _, m := range meta {
+		next[m.Filename] = m
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := false
+	for filename, old := range r.resources {
+		if _, ok := next[filename]; !ok {
+			srv.RemoveResource(old.URI)
+			slog.Info("resource removed", "uri", old.URI)
+			changed = true
+		}
+	}
+	for filename, m := range next {
+		old, ok := r.resources[filename]
+		if ok && old == m {
+			continue
+		}
+		if ok && old.URI != m.URI {
+			srv.RemoveResource(old.URI)
+		}
+		registerResource(srv, resourcesPath, m)
+		slog.Info("resource registered", "uri", m.URI)
+		changed = true
+	}
+	r.resources = next
+
+	if changed {
+		srv.SendNotificationToAllClients("notifications/resources/list_changed", nil)
+	}
+	return nil
+}
+
+func (r *registry) reloadPrompts(srv *server.MCPServer, promptsPath string) error {
+	meta, err := loadPromptMeta(promptsPath)
+	if err != nil {
+		return err
+	}
+	next := make(map[string]PromptMeta, len(meta))
+	for _, m := range meta {
+		next[m.Filename] = m
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := false
+	for filename, old := range r.prompts {
+		if _, ok := next[filename]; !ok {
+			srv.RemovePrompt(old.Name)
+			InvalidateTemplate(filename)
+			slog.Info("prompt removed", "name", old.Name)
+			changed = true
+		}
+	}
+	for filename, m := range next {
+		old, ok := r.prompts[filename]
+		if ok && reflect.DeepEqual(old, m) {
+			continue
+		}
+		if ok && old.Name != m.Name {
+			srv.RemovePrompt(old.Name)
+		}
+		registerPrompt(srv, promptsPath, m)
+		slog.Info("prompt registered", "name", m.Name)
+		changed = true
+	}
+	r.prompts = next
+
+	if changed {
+		srv.SendNotificationToAllClients("notifications/prompts/list_changed", nil)
+	}
+	return nil
+}